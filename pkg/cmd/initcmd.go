@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/url"
+	"os"
+	"os/exec"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -12,87 +17,228 @@ import (
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	sshtransport "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/kevinburke/ssh_config"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+	cryptossh "golang.org/x/crypto/ssh"
 
 	"github.com/twpayne/chezmoi/v2/pkg/chezmoi"
 )
 
 type initCmdConfig struct {
-	apply             bool
-	branch            string
-	configPath        chezmoi.AbsPath
-	data              bool
-	depth             int
-	filter            *chezmoi.EntryTypeFilter
-	guessRepoURL      bool
-	oneShot           bool
-	forcePromptOnce   bool
-	promptBool        map[string]string
-	promptInt         map[string]int
-	promptString      map[string]string
-	purge             bool
-	purgeBinary       bool
-	recurseSubmodules bool
-	ssh               bool
+	apply              bool
+	branch             string
+	configPath         chezmoi.AbsPath
+	data               bool
+	depth              int
+	filter             *chezmoi.EntryTypeFilter
+	partialCloneFilter string
+	guessRepoURL       bool
+	oneShot            bool
+	forcePromptOnce    bool
+	promptBool         map[string]string
+	promptInt          map[string]int
+	promptString       map[string]string
+	mirrors            []string
+	purge              bool
+	purgeBinary        bool
+	recurseSubmodules  bool
+	repoGuess          []repoGuessConfig
+	ssh                bool
+	verify             bool
+	verifySignersPath  chezmoi.AbsPath
 }
 
-var repoGuesses = []struct {
+// A repoURLCandidate is a repo URL to attempt to clone from, together with
+// the username guessed for it, if any.
+type repoURLCandidate struct {
+	username string
+	url      string
+}
+
+// A repoGuess is an entry in repoGuesses describing how to turn a short arg
+// like "user" or "user/repo" into a full repo URL.
+type repoGuess struct {
 	rx                *regexp.Regexp
 	httpRepoGuessRepl string
 	sshRepoGuessRepl  string
 	usernameGuessRepl string
-}{
-	{
+	defaultAuthMethod string // "http" or "ssh"; preferred candidate order when --ssh is not given explicitly
+}
+
+// repoGuesses is the registry that runInitCmd passes to guessRepoURLs, in
+// order. Entries are contributed by registerRepoGuessProvider, which
+// providers call from an init function, mirroring how go-git's
+// transport/client.InstallProtocol teaches go-git about new URL schemes.
+// This lets chezmoi init recognize hosting providers beyond the hardcoded
+// GitHub and sr.ht cases (for example gitea://, sourcehut://, codeberg://,
+// or azuredevops://) without users having to type a full URL, and lets users
+// contribute their own with repeated --repo-guess flags (see repoGuessConfig
+// and repoGuessConfigsValue). User entries are not read from the config
+// file.
+var repoGuesses []repoGuess
+
+// registerRepoGuessProvider appends guess to repoGuesses. It is called from
+// init functions in this package. User-configured entries are not registered
+// here; runInitCmd appends them to a copy of repoGuesses.
+func registerRepoGuessProvider(guess repoGuess) {
+	repoGuesses = append(repoGuesses, guess)
+}
+
+func init() {
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\A([-0-9A-Za-z]+)\z`),
 		httpRepoGuessRepl: "https://github.com/$1/dotfiles.git",
 		sshRepoGuessRepl:  "git@github.com:$1/dotfiles.git",
 		usernameGuessRepl: "$1",
-	},
-	{
+	})
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\A([-0-9A-Za-z]+)/([-0-9A-Za-z]+)(\.git)?\z`),
 		httpRepoGuessRepl: "https://github.com/$1/$2.git",
 		sshRepoGuessRepl:  "git@github.com:$1/$2.git",
 		usernameGuessRepl: "$1",
-	},
-	{
+	})
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\A([-.0-9A-Za-z]+)/([-0-9A-Za-z]+)\z`),
 		httpRepoGuessRepl: "https://$1/$2/dotfiles.git",
 		sshRepoGuessRepl:  "git@$1:$2/dotfiles.git",
 		usernameGuessRepl: "$2",
-	},
-	{
+	})
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\A([-0-9A-Za-z]+)/([-0-9A-Za-z]+)/([-.0-9A-Za-z]+)\z`),
 		httpRepoGuessRepl: "https://$1/$2/$3.git",
 		sshRepoGuessRepl:  "git@$1:$2/$3.git",
 		usernameGuessRepl: "$2",
-	},
-	{
+	})
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\A([-.0-9A-Za-z]+)/([-0-9A-Za-z]+)/([-0-9A-Za-z]+)(\.git)?\z`),
 		httpRepoGuessRepl: "https://$1/$2/$3.git",
 		sshRepoGuessRepl:  "git@$1:$2/$3.git",
 		usernameGuessRepl: "$2",
-	},
-	{
+	})
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\A(https?://)([-.0-9A-Za-z]+)/([-0-9A-Za-z]+)/([-0-9A-Za-z]+)(\.git)?\z`),
 		httpRepoGuessRepl: "$1$2/$3/$4.git",
 		sshRepoGuessRepl:  "git@$2:$3/$4.git",
 		usernameGuessRepl: "$3",
-	},
-	{
+	})
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\Asr\.ht/~([a-z_][a-z0-9_-]+)\z`),
 		httpRepoGuessRepl: "https://git.sr.ht/~$1/dotfiles",
 		sshRepoGuessRepl:  "git@git.sr.ht:~$1/dotfiles",
 		usernameGuessRepl: "$1",
-	},
-	{
+	})
+	registerRepoGuessProvider(repoGuess{
 		rx:                regexp.MustCompile(`\Asr\.ht/~([a-z_][a-z0-9_-]+)/([-0-9A-Za-z]+)\z`),
 		httpRepoGuessRepl: "https://git.sr.ht/~$1/$2",
 		sshRepoGuessRepl:  "git@git.sr.ht:~$1/$2",
 		usernameGuessRepl: "$1",
-	},
+	})
+	registerRepoGuessProvider(repoGuess{
+		rx:                regexp.MustCompile(`\Agitea://([-.0-9A-Za-z]+)/([-0-9A-Za-z]+)/([-0-9A-Za-z]+)(\.git)?\z`),
+		httpRepoGuessRepl: "https://$1/$2/$3.git",
+		sshRepoGuessRepl:  "git@$1:$2/$3.git",
+		usernameGuessRepl: "$2",
+		defaultAuthMethod: "http",
+	})
+	registerRepoGuessProvider(repoGuess{
+		rx:                regexp.MustCompile(`\Asourcehut://~([a-z_][a-z0-9_-]+)/([-0-9A-Za-z]+)\z`),
+		httpRepoGuessRepl: "https://git.sr.ht/~$1/$2",
+		sshRepoGuessRepl:  "git@git.sr.ht:~$1/$2",
+		usernameGuessRepl: "$1",
+		defaultAuthMethod: "http",
+	})
+	registerRepoGuessProvider(repoGuess{
+		rx:                regexp.MustCompile(`\Acodeberg://([-0-9A-Za-z]+)/([-0-9A-Za-z]+)(\.git)?\z`),
+		httpRepoGuessRepl: "https://codeberg.org/$1/$2.git",
+		sshRepoGuessRepl:  "git@codeberg.org:$1/$2.git",
+		usernameGuessRepl: "$1",
+		defaultAuthMethod: "http",
+	})
+	registerRepoGuessProvider(repoGuess{
+		rx:                regexp.MustCompile(`\Aazuredevops://([-0-9A-Za-z]+)/([-0-9A-Za-z]+)/([-0-9A-Za-z]+)\z`),
+		httpRepoGuessRepl: "https://dev.azure.com/$1/$2/_git/$3",
+		sshRepoGuessRepl:  "git@ssh.dev.azure.com:v3/$1/$2/$3",
+		usernameGuessRepl: "$1",
+		defaultAuthMethod: "ssh",
+	})
+}
+
+// A repoGuessConfig is a user-contributed repoGuess, configured with a
+// --repo-guess flag, which can be repeated.
+type repoGuessConfig struct {
+	Pattern    string `json:"pattern"    mapstructure:"pattern"    yaml:"pattern"`
+	HTTPURL    string `json:"httpURL"    mapstructure:"httpURL"    yaml:"httpURL"`
+	SSHURL     string `json:"sshURL"     mapstructure:"sshURL"     yaml:"sshURL"`
+	Username   string `json:"username"   mapstructure:"username"   yaml:"username"`
+	AuthMethod string `json:"auth"       mapstructure:"auth"       yaml:"auth"` // "http" or "ssh"
+}
+
+// repoGuess returns the repoGuess represented by c, or an error if c.Pattern
+// is not a valid regular expression.
+func (c repoGuessConfig) repoGuess() (repoGuess, error) {
+	rx, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return repoGuess{}, fmt.Errorf("init.repoGuess: %s: %w", c.Pattern, err)
+	}
+	return repoGuess{
+		rx:                rx,
+		httpRepoGuessRepl: c.HTTPURL,
+		sshRepoGuessRepl:  c.SSHURL,
+		defaultAuthMethod: c.AuthMethod,
+		usernameGuessRepl: c.Username,
+	}, nil
+}
+
+// A repoGuessConfigsValue is a pflag.Value that appends a repoGuessConfig to
+// repoGuessConfigs each time it is set, allowing --repo-guess to be repeated
+// on the command line.
+type repoGuessConfigsValue struct {
+	repoGuessConfigs *[]repoGuessConfig
+}
+
+func (v *repoGuessConfigsValue) String() string {
+	return ""
+}
+
+func (v *repoGuessConfigsValue) Type() string {
+	return "repoGuess"
+}
+
+// Set parses s, a comma-separated list of key=value pairs (keys: pattern,
+// httpURL, sshURL, username, auth), and appends the resulting
+// repoGuessConfig to v.repoGuessConfigs.
+func (v *repoGuessConfigsValue) Set(s string) error {
+	var config repoGuessConfig
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("%s: invalid --repo-guess field, expected key=value", field)
+		}
+		switch key {
+		case "pattern":
+			config.Pattern = value
+		case "httpURL":
+			config.HTTPURL = value
+		case "sshURL":
+			config.SSHURL = value
+		case "username":
+			config.Username = value
+		case "auth":
+			config.AuthMethod = value
+		default:
+			return fmt.Errorf("%s: unknown --repo-guess key", key)
+		}
+	}
+	if config.Pattern == "" {
+		return errors.New("--repo-guess: pattern is required")
+	}
+	*v.repoGuessConfigs = append(*v.repoGuessConfigs, config)
+	return nil
 }
 
 // A loggableGitCloneOptions is a git.CloneOptions that implements
@@ -123,9 +269,11 @@ func (c *Config) newInitCmd() *cobra.Command {
 	flags.BoolVar(&c.init.data, "data", c.init.data, "Include existing template data")
 	flags.IntVarP(&c.init.depth, "depth", "d", c.init.depth, "Create a shallow clone")
 	flags.VarP(c.init.filter.Exclude, "exclude", "x", "Exclude entry types")
-	flags.BoolVar(&c.init.forcePromptOnce, "prompt", c.init.forcePromptOnce, "Force prompt*Once template functions to prompt") //nolint:lll
+	flags.StringVar(&c.init.partialCloneFilter, "filter", c.init.partialCloneFilter, "Partial clone filter, e.g. blob:none or tree:0") //nolint:lll
+	flags.BoolVar(&c.init.forcePromptOnce, "prompt", c.init.forcePromptOnce, "Force prompt*Once template functions to prompt")         //nolint:lll
 	flags.BoolVarP(&c.init.guessRepoURL, "guess-repo-url", "g", c.init.guessRepoURL, "Guess the repo URL")
 	flags.VarP(c.init.filter.Include, "include", "i", "Include entry types")
+	flags.StringSliceVar(&c.init.mirrors, "mirror", c.init.mirrors, "Additional repo URLs to try in order if the primary URL fails")
 	flags.BoolVar(&c.init.oneShot, "one-shot", c.init.oneShot, "Run in one-shot mode")
 	flags.StringToStringVar(&c.init.promptBool, "promptBool", c.init.promptBool, "Populate promptBool")
 	flags.StringToIntVar(&c.init.promptInt, "promptInt", c.init.promptInt, "Populate promptInt")
@@ -133,7 +281,15 @@ func (c *Config) newInitCmd() *cobra.Command {
 	flags.BoolVarP(&c.init.purge, "purge", "p", c.init.purge, "Purge config and source directories after running")
 	flags.BoolVarP(&c.init.purgeBinary, "purge-binary", "P", c.init.purgeBinary, "Purge chezmoi binary after running")
 	flags.BoolVar(&c.init.recurseSubmodules, "recurse-submodules", c.init.recurseSubmodules, "Checkout submodules recursively") //nolint:lll
+	flags.Var(
+		&repoGuessConfigsValue{repoGuessConfigs: &c.init.repoGuess},
+		"repo-guess",
+		"Add a repo URL guess rule (pattern=...,httpURL=...,sshURL=...,username=...,auth=http|ssh); "+
+			"can be repeated",
+	)
 	flags.BoolVar(&c.init.ssh, "ssh", c.init.ssh, "Use ssh instead of https when guessing repo URL")
+	flags.BoolVar(&c.init.verify, "verify", c.init.verify, "Verify the signature of the cloned repo's HEAD commit")
+	flags.Var(&c.init.verifySignersPath, "verify-signers-file", "Path to an allowed signers file to verify against")
 
 	return initCmd
 }
@@ -170,50 +326,49 @@ func (c *Config) runInitCmd(cmd *cobra.Command, args []string) error {
 				return err
 			}
 		} else {
-			var username, repoURLStr string
+			var candidates []repoURLCandidate
 			if c.init.guessRepoURL {
-				username, repoURLStr = guessRepoURL(args[0], c.init.ssh)
+				guesses := make([]repoGuess, len(repoGuesses), len(repoGuesses)+len(c.init.repoGuess))
+				copy(guesses, repoGuesses)
+				for _, repoGuessConfig := range c.init.repoGuess {
+					repoGuess, err := repoGuessConfig.repoGuess()
+					if err != nil {
+						return err
+					}
+					guesses = append(guesses, repoGuess)
+				}
+				candidates = guessRepoURLs(args[0], c.init.ssh, cmd.Flags().Changed("ssh"), guesses)
 			} else {
-				repoURLStr = args[0]
+				candidates = []repoURLCandidate{{url: args[0]}}
+			}
+			for _, mirror := range c.init.mirrors {
+				candidates = append(candidates, repoURLCandidate{url: mirror})
+			}
+
+			if useBuiltinGit && c.init.partialCloneFilter != "" {
+				// The builtin git client cannot request a partial clone
+				// filter from the upload-pack service, so fall back to the
+				// external git command, which can.
+				c.logger.Info().
+					Str("filter", c.init.partialCloneFilter).
+					Msg("falling back to external git for partial clone")
+				useBuiltinGit = false
 			}
+
 			if useBuiltinGit {
-				if err := c.builtinGitClone(username, repoURLStr, workingTreeRawPath); err != nil {
+				if err := c.builtinGitClone(candidates, workingTreeRawPath); err != nil {
 					return err
 				}
-			} else {
-				args := []string{
-					"clone",
-				}
-				if c.init.recurseSubmodules {
-					args = append(args,
-						"--recurse-submodules",
-					)
-				}
-				if c.init.branch != "" {
-					args = append(args,
-						"--branch", c.init.branch,
-					)
-				}
-				if c.init.depth != 0 {
-					args = append(args,
-						"--depth", strconv.Itoa(c.init.depth),
-					)
-				}
-				if c.init.guessRepoURL && (strings.HasPrefix(repoURLStr, "http://") || strings.HasPrefix(repoURLStr, "https://")) {
-					repoURL, err := url.Parse(repoURLStr)
-					if err != nil {
+			} else if err := c.externalGitClone(candidates, workingTreeRawPath); err != nil {
+				return err
+			}
+
+			if c.init.verify {
+				if useBuiltinGit {
+					if err := c.verifyHEAD(workingTreeRawPath); err != nil {
 						return err
 					}
-					if repoURL.User == nil {
-						repoURL.User = url.User(username)
-						repoURLStr = repoURL.String()
-					}
-				}
-				args = append(args,
-					repoURLStr,
-					workingTreeRawPath.String(),
-				)
-				if err := c.run(chezmoi.EmptyAbsPath, c.Git.Command, args); err != nil {
+				} else if err := c.externalVerifyCommit(c.WorkingTreeAbsPath); err != nil {
 					return err
 				}
 			}
@@ -262,15 +417,44 @@ func (c *Config) runInitCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// builtinGitClone clones a repo using the builtin git command.
-func (c *Config) builtinGitClone(username, repoURLStr string, workingTreeRawPath chezmoi.AbsPath) error {
+// builtinGitClone clones a repo using the builtin git command, trying each of
+// candidates in order until one succeeds. If cloning from a candidate fails
+// with transport.ErrRepositoryNotFound, that error is returned immediately;
+// any other network or authentication error moves on to the next candidate,
+// and the final candidate's error is returned if none succeed.
+func (c *Config) builtinGitClone(candidates []repoURLCandidate, workingTreeRawPath chezmoi.AbsPath) error {
+	var err error
+	for i, candidate := range candidates {
+		if err = c.builtinGitCloneOne(candidate.username, candidate.url, workingTreeRawPath); err == nil {
+			return nil
+		}
+		if shouldStopTryingMirrors(err) {
+			return err
+		}
+		if i < len(candidates)-1 {
+			if _, ferr := fmt.Fprintf(c.stdout, "chezmoi: %s: %v, trying next mirror\n", candidate.url, err); ferr != nil {
+				return ferr
+			}
+		}
+	}
+	return err
+}
+
+// shouldStopTryingMirrors reports whether builtinGitClone should give up
+// immediately after err rather than trying the next candidate. A repo that
+// definitively does not exist at a candidate URL is not expected to exist at
+// the remaining candidates either (they are guesses or mirrors of the same
+// repo), so there is no point in trying them.
+func shouldStopTryingMirrors(err error) bool {
+	return errors.Is(err, transport.ErrRepositoryNotFound)
+}
+
+// builtinGitCloneOne clones a single repo using the builtin git command.
+func (c *Config) builtinGitCloneOne(username, repoURLStr string, workingTreeRawPath chezmoi.AbsPath) error {
 	endpoint, err := transport.NewEndpoint(repoURLStr)
 	if err != nil {
 		return err
 	}
-	if c.init.ssh || endpoint.Protocol == "ssh" {
-		return errors.New("builtin git does not support cloning repos over ssh, please install git")
-	}
 
 	isBare := false
 	var referenceName plumbing.ReferenceName
@@ -284,32 +468,304 @@ func (c *Config) builtinGitClone(username, repoURLStr string, workingTreeRawPath
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 	}
 
+	isSSH := c.init.ssh || endpoint.Protocol == "ssh"
+	if isSSH && endpoint.User != "" {
+		username = endpoint.User
+	}
+
+	var passphrase string
 	for {
-		_, err := git.PlainClone(workingTreeRawPath.String(), isBare, &cloneOptions)
-		c.logger.Err(err).
-			Stringer("path", workingTreeRawPath).
-			Bool("isBare", isBare).
-			Object("o", loggableGitCloneOptions(cloneOptions)).
-			Msg("PlainClone")
-		if !errors.Is(err, transport.ErrAuthenticationRequired) {
+		var err error
+		if isSSH {
+			if cloneOptions.Auth, err = c.sshAuthMethod(endpoint.Host, username, passphrase); err != nil {
+				if !isIncorrectPassphraseError(err) {
+					return err
+				}
+			}
+		}
+
+		if err == nil {
+			_, err = git.PlainClone(workingTreeRawPath.String(), isBare, &cloneOptions)
+			c.logger.Err(err).
+				Stringer("path", workingTreeRawPath).
+				Bool("isBare", isBare).
+				Object("o", loggableGitCloneOptions(cloneOptions)).
+				Msg("PlainClone")
+		}
+
+		switch {
+		case err == nil:
+			return nil
+		case isSSH && isIncorrectPassphraseError(err):
+			if passphrase, err = c.readPassword(fmt.Sprintf("Passphrase for %s? ", endpoint.Host)); err != nil {
+				return err
+			}
+		case !isSSH && errors.Is(err, transport.ErrAuthenticationRequired):
+			if _, err := fmt.Fprintf(c.stdout, "chezmoi: %s: %v\n", repoURLStr, err); err != nil {
+				return err
+			}
+			var basicAuth http.BasicAuth
+			if basicAuth.Username, err = c.readString("Username? ", &username); err != nil {
+				return err
+			}
+			if basicAuth.Username == "" {
+				basicAuth.Username = username
+			}
+			if basicAuth.Password, err = c.readPassword("Password? "); err != nil {
+				return err
+			}
+			cloneOptions.Auth = &basicAuth
+		default:
 			return err
 		}
+	}
+}
 
-		if _, err := fmt.Fprintf(c.stdout, "chezmoi: %s: %v\n", repoURLStr, err); err != nil {
+// isIncorrectPassphraseError returns whether err indicates that an encrypted
+// SSH private key needs a passphrase, either because none was given yet
+// (cryptossh.PassphraseMissingError, from an empty passphrase) or because
+// the given one was wrong (x509.IncorrectPasswordError, from
+// ssh.ParsePrivateKeyWithPassphrase).
+func isIncorrectPassphraseError(err error) bool {
+	var passphraseMissingError *cryptossh.PassphraseMissingError
+	if errors.As(err, &passphraseMissingError) {
+		return true
+	}
+	return errors.Is(err, x509.IncorrectPasswordError) ||
+		strings.Contains(err.Error(), "x509: decryption password incorrect")
+}
+
+// sshAuthMethod returns a transport.AuthMethod for cloning from host as
+// username. It honors IdentityFile and IdentitiesOnly from the user's
+// ~/.ssh/config, decrypting the key with passphrase if one is given, and falls back to
+// SSH_AUTH_SOCK (ssh-agent) when no explicit key is configured.
+func (c *Config) sshAuthMethod(host, username, passphrase string) (transport.AuthMethod, error) {
+	identityFile := ssh_config.Get(host, "IdentityFile")
+	identitiesOnly := strings.EqualFold(ssh_config.Get(host, "IdentitiesOnly"), "yes")
+
+	if identityFile != "" {
+		identityFileAbsPath, err := chezmoi.NewAbsPathFromExtPath(identityFile, c.homeDirAbsPath)
+		if err != nil {
+			return nil, err
+		}
+		publicKeys, err := sshtransport.NewPublicKeysFromFile(username, identityFileAbsPath.String(), passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return publicKeys, nil
+	}
+
+	if identitiesOnly {
+		return nil, fmt.Errorf("%s: IdentitiesOnly is set but no IdentityFile is configured", host)
+	}
+
+	if sshAuthSock := os.Getenv("SSH_AUTH_SOCK"); sshAuthSock != "" {
+		return sshtransport.NewSSHAgentAuth(username)
+	}
+
+	return nil, fmt.Errorf("%s: no SSH key available, set IdentityFile in ~/.ssh/config or start ssh-agent", host)
+}
+
+// verifyHEAD verifies the signature of the HEAD commit of the repo at
+// workingTreeRawPath. GPG-signed commits are verified with go-git's OpenPGP
+// verifier against the armored keyring at c.init.verifySignersPath; the
+// user's default GPG keyring is not read, so if c.init.verifySignersPath is
+// empty a GPG-signed commit fails verification. SSH-signed commits are
+// verified against the allowed
+// signers file at c.init.verifySignersPath using ssh-keygen -Y verify. It
+// returns an error if HEAD is unsigned or its signature does not verify.
+func (c *Config) verifyHEAD(workingTreeRawPath chezmoi.AbsPath) error {
+	repo, err := git.PlainOpen(workingTreeRawPath.String())
+	if err != nil {
+		return err
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return err
+	}
+	if commit.PGPSignature == "" {
+		return fmt.Errorf("%s: HEAD commit %s is not signed", workingTreeRawPath, headRef.Hash())
+	}
+
+	if strings.HasPrefix(commit.PGPSignature, sshSignatureHeader) {
+		if err := c.verifySSHCommitSignature(commit); err != nil {
+			return fmt.Errorf("%s: HEAD commit %s: %w", workingTreeRawPath, headRef.Hash(), err)
+		}
+		c.logger.Info().
+			Stringer("path", workingTreeRawPath).
+			Stringer("hash", headRef.Hash()).
+			Msg("verifyHEAD")
+		return nil
+	}
+
+	var allowedSigners string
+	if !c.init.verifySignersPath.Empty() {
+		data, err := c.baseSystem.ReadFile(c.init.verifySignersPath)
+		if err != nil {
 			return err
 		}
-		var basicAuth http.BasicAuth
-		if basicAuth.Username, err = c.readString("Username? ", &username); err != nil {
+		allowedSigners = string(data)
+	}
+
+	entity, err := commit.Verify(allowedSigners)
+	if err != nil {
+		return fmt.Errorf("%s: HEAD commit %s: signature verification failed: %w", workingTreeRawPath, headRef.Hash(), err)
+	}
+
+	c.logger.Info().
+		Stringer("path", workingTreeRawPath).
+		Stringer("hash", headRef.Hash()).
+		Str("keyID", entity.PrimaryKey.KeyIdShortString()).
+		Msg("verifyHEAD")
+
+	return nil
+}
+
+// sshSignatureHeader is the first line of an SSH (as opposed to OpenPGP)
+// commit signature, as produced by ssh-keygen -Y sign and stored verbatim in
+// the commit's gpgsig header by git when gpg.format=ssh.
+const sshSignatureHeader = "-----BEGIN SSH SIGNATURE-----"
+
+// verifySSHCommitSignature verifies commit's SSH signature against the
+// allowed signers file at c.init.verifySignersPath by shelling out to
+// ssh-keygen -Y verify, go-git having no native support for SSH commit
+// signatures.
+func (c *Config) verifySSHCommitSignature(commit *object.Commit) error {
+	if c.init.verifySignersPath.Empty() {
+		return errors.New("--verify-signers-file is required to verify SSH commit signatures")
+	}
+	allowedSignersRawPath, err := c.baseSystem.RawPath(c.init.verifySignersPath)
+	if err != nil {
+		return err
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return err
+	}
+	payloadReader, err := encoded.Reader()
+	if err != nil {
+		return err
+	}
+	payload, err := io.ReadAll(payloadReader)
+	if err != nil {
+		return err
+	}
+
+	sigFile, err := os.CreateTemp("", "chezmoi-verify-commit-*.sig")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sigFile.Name())
+	_, writeErr := sigFile.WriteString(commit.PGPSignature)
+	closeErr := sigFile.Close()
+	if writeErr != nil {
+		return writeErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	//nolint:gosec
+	verifyCmd := exec.Command(
+		"ssh-keygen", "-Y", "verify",
+		"-f", allowedSignersRawPath.String(),
+		"-I", commit.Committer.Email,
+		"-n", "git",
+		"-s", sigFile.Name(),
+	)
+	verifyCmd.Stdin = bytes.NewReader(payload)
+	output, err := verifyCmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ssh-keygen -Y verify: %w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// externalVerifyCommit verifies HEAD's signature in the working tree at
+// workingTreeAbsPath by shelling out to git verify-commit. If
+// c.init.verifySignersPath is set, it is passed as gpg.ssh.allowedSignersFile
+// so that SSH-signed commits are checked against it rather than whatever
+// allowed-signers file (if any) the user's global git config points to. Git
+// has no equivalent per-invocation override for OpenPGP verification, so
+// GPG-signed commits are still checked against the user's default keyring,
+// exactly as external git would without --verify.
+func (c *Config) externalVerifyCommit(workingTreeAbsPath chezmoi.AbsPath) error {
+	var args []string
+	if !c.init.verifySignersPath.Empty() {
+		allowedSignersRawPath, err := c.baseSystem.RawPath(c.init.verifySignersPath)
+		if err != nil {
 			return err
 		}
-		if basicAuth.Username == "" {
-			basicAuth.Username = username
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+allowedSignersRawPath.String())
+	}
+	args = append(args, "verify-commit", "HEAD")
+	return c.run(workingTreeAbsPath, c.Git.Command, args)
+}
+
+// externalGitClone clones a repo using the external git command, trying each
+// of candidates in order until one succeeds. The final candidate's error is
+// returned if none succeed.
+func (c *Config) externalGitClone(candidates []repoURLCandidate, workingTreeRawPath chezmoi.AbsPath) error {
+	var err error
+	for i, candidate := range candidates {
+		if err = c.externalGitCloneOne(candidate, workingTreeRawPath); err == nil {
+			return nil
+		}
+		if i < len(candidates)-1 {
+			if _, ferr := fmt.Fprintf(c.stdout, "chezmoi: %s: %v, trying next mirror\n", candidate.url, err); ferr != nil {
+				return ferr
+			}
 		}
-		if basicAuth.Password, err = c.readPassword("Password? "); err != nil {
+	}
+	return err
+}
+
+// externalGitCloneOne clones a single repo using the external git command.
+func (c *Config) externalGitCloneOne(candidate repoURLCandidate, workingTreeRawPath chezmoi.AbsPath) error {
+	repoURLStr := candidate.url
+	args := []string{
+		"clone",
+	}
+	if c.init.recurseSubmodules {
+		args = append(args,
+			"--recurse-submodules",
+		)
+	}
+	if c.init.branch != "" {
+		args = append(args,
+			"--branch", c.init.branch,
+		)
+	}
+	if c.init.depth != 0 {
+		args = append(args,
+			"--depth", strconv.Itoa(c.init.depth),
+		)
+	}
+	if c.init.partialCloneFilter != "" {
+		args = append(args,
+			"--filter", c.init.partialCloneFilter,
+		)
+	}
+	if candidate.username != "" && (strings.HasPrefix(repoURLStr, "http://") || strings.HasPrefix(repoURLStr, "https://")) {
+		repoURL, err := url.Parse(repoURLStr)
+		if err != nil {
 			return err
 		}
-		cloneOptions.Auth = &basicAuth
+		if repoURL.User == nil {
+			repoURL.User = url.User(candidate.username)
+			repoURLStr = repoURL.String()
+		}
 	}
+	args = append(args,
+		repoURLStr,
+		workingTreeRawPath.String(),
+	)
+	return c.run(chezmoi.EmptyAbsPath, c.Git.Command, args)
 }
 
 // builtinGitInit initializes a repo using the builtin git command.
@@ -364,22 +820,51 @@ func (o loggableGitCloneOptions) MarshalZerologObject(e *zerolog.Event) {
 	}
 }
 
-// guessRepoURL guesses the user's username and repo from arg.
-func guessRepoURL(arg string, ssh bool) (username, repo string) {
-	for _, repoGuess := range repoGuesses {
+// guessRepoURLs guesses repo URL candidates from arg. If sshExplicit is
+// false (the user did not pass --ssh explicitly) and arg matches a
+// repoGuess with both HTTP and SSH replacements, both candidates are
+// returned, ordered according to the matching repoGuess's
+// defaultAuthMethod (HTTPS first unless defaultAuthMethod is "ssh"), so that
+// the caller can fall back to the other protocol if the preferred candidate
+// fails. If sshExplicit is true, only the candidate for the requested
+// protocol (SSH if ssh is true, HTTPS otherwise) is returned. guesses is
+// consulted in order; callers
+// combine repoGuesses with any user-configured entries rather than mutating
+// the package-level registry.
+func guessRepoURLs(arg string, ssh, sshExplicit bool, guesses []repoGuess) []repoURLCandidate {
+	for _, repoGuess := range guesses {
 		if !repoGuess.rx.MatchString(arg) {
 			continue
 		}
-		switch {
-		case ssh && repoGuess.sshRepoGuessRepl != "":
-			repo = repoGuess.rx.ReplaceAllString(arg, repoGuess.sshRepoGuessRepl)
-			return
-		case !ssh && repoGuess.httpRepoGuessRepl != "":
-			username = repoGuess.rx.ReplaceAllString(arg, repoGuess.usernameGuessRepl)
-			repo = repoGuess.rx.ReplaceAllString(arg, repoGuess.httpRepoGuessRepl)
-			return
+		username := repoGuess.rx.ReplaceAllString(arg, repoGuess.usernameGuessRepl)
+		addHTTP := func(candidates []repoURLCandidate) []repoURLCandidate {
+			if (!sshExplicit || !ssh) && repoGuess.httpRepoGuessRepl != "" {
+				candidates = append(candidates, repoURLCandidate{
+					username: username,
+					url:      repoGuess.rx.ReplaceAllString(arg, repoGuess.httpRepoGuessRepl),
+				})
+			}
+			return candidates
+		}
+		addSSH := func(candidates []repoURLCandidate) []repoURLCandidate {
+			if (!sshExplicit || ssh) && repoGuess.sshRepoGuessRepl != "" {
+				candidates = append(candidates, repoURLCandidate{
+					username: username,
+					url:      repoGuess.rx.ReplaceAllString(arg, repoGuess.sshRepoGuessRepl),
+				})
+			}
+			return candidates
+		}
+
+		var candidates []repoURLCandidate
+		if repoGuess.defaultAuthMethod == "ssh" {
+			candidates = addHTTP(addSSH(candidates))
+		} else {
+			candidates = addSSH(addHTTP(candidates))
+		}
+		if len(candidates) > 0 {
+			return candidates
 		}
 	}
-	repo = arg
-	return
+	return []repoURLCandidate{{url: arg}}
 }
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+func TestGuessRepoURLs(t *testing.T) {
+	guesses := []repoGuess{
+		{
+			rx:                regexp.MustCompile(`\A([-\w]+)\z`),
+			httpRepoGuessRepl: "https://github.com/$1/dotfiles.git",
+			sshRepoGuessRepl:  "git@github.com:$1/dotfiles.git",
+			usernameGuessRepl: "$1",
+			defaultAuthMethod: "http",
+		},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		arg         string
+		ssh         bool
+		sshExplicit bool
+		guesses     []repoGuess
+		want        []repoURLCandidate
+	}{
+		{
+			name: "no_match_returns_arg_verbatim",
+			arg:  "https://example.com/user/repo.git",
+			want: []repoURLCandidate{{url: "https://example.com/user/repo.git"}},
+		},
+		{
+			name:    "match_prefers_http_by_default",
+			arg:     "user",
+			guesses: guesses,
+			want: []repoURLCandidate{
+				{username: "user", url: "https://github.com/user/dotfiles.git"},
+				{username: "user", url: "git@github.com:user/dotfiles.git"},
+			},
+		},
+		{
+			name:        "explicit_ssh_only_returns_ssh",
+			arg:         "user",
+			ssh:         true,
+			sshExplicit: true,
+			guesses:     guesses,
+			want: []repoURLCandidate{
+				{username: "user", url: "git@github.com:user/dotfiles.git"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := guessRepoURLs(tc.arg, tc.ssh, tc.sshExplicit, tc.guesses)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("guessRepoURLs(%q, %v, %v) = %#v, want %#v", tc.arg, tc.ssh, tc.sshExplicit, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepoGuessConfigsValueSet(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		s       string
+		want    repoGuessConfig
+		wantErr bool
+	}{
+		{
+			name: "all_fields",
+			s:    "pattern=^(\\w+)$,httpURL=https://example.com/$1.git,sshURL=git@example.com:$1.git,username=$1,auth=ssh",
+			want: repoGuessConfig{
+				Pattern:    `^(\w+)$`,
+				HTTPURL:    "https://example.com/$1.git",
+				SSHURL:     "git@example.com:$1.git",
+				Username:   "$1",
+				AuthMethod: "ssh",
+			},
+		},
+		{
+			name:    "missing_pattern",
+			s:       "httpURL=https://example.com/$1.git",
+			wantErr: true,
+		},
+		{
+			name:    "unknown_key",
+			s:       "pattern=x,bogus=y",
+			wantErr: true,
+		},
+		{
+			name:    "missing_equals",
+			s:       "pattern",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var configs []repoGuessConfig
+			v := repoGuessConfigsValue{repoGuessConfigs: &configs}
+			err := v.Set(tc.s)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Set(%q) returned nil error, want error", tc.s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set(%q) returned %v, want nil", tc.s, err)
+			}
+			if len(configs) != 1 || configs[0] != tc.want {
+				t.Errorf("Set(%q) appended %#v, want %#v", tc.s, configs, []repoGuessConfig{tc.want})
+			}
+		})
+	}
+}
+
+func TestIsIncorrectPassphraseError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "passphrase_missing",
+			err:  &cryptossh.PassphraseMissingError{},
+			want: true,
+		},
+		{
+			name: "x509_incorrect_password",
+			err:  x509.IncorrectPasswordError,
+			want: true,
+		},
+		{
+			name: "wrapped_x509_incorrect_password",
+			err:  fmt.Errorf("decrypt: %w", x509.IncorrectPasswordError),
+			want: true,
+		},
+		{
+			name: "unrelated_error",
+			err:  errors.New("network is unreachable"),
+			want: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isIncorrectPassphraseError(tc.err); got != tc.want {
+				t.Errorf("isIncorrectPassphraseError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestShouldStopTryingMirrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "repository_not_found_stops",
+			err:  transport.ErrRepositoryNotFound,
+			want: true,
+		},
+		{
+			name: "wrapped_repository_not_found_stops",
+			err:  fmt.Errorf("clone: %w", transport.ErrRepositoryNotFound),
+			want: true,
+		},
+		{
+			name: "authentication_required_continues",
+			err:  transport.ErrAuthenticationRequired,
+			want: false,
+		},
+		{
+			name: "unrelated_error_continues",
+			err:  errors.New("connection reset"),
+			want: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := shouldStopTryingMirrors(tc.err); got != tc.want {
+				t.Errorf("shouldStopTryingMirrors(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}